@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ApplyOptions controls how ApplyAndWait performs its apply, mirroring the parameters
+// ResourceHandler.Apply takes plus the optional post-apply readiness wait.
+type ApplyOptions struct {
+	FieldManager string
+	Force        bool
+
+	// WaitAfterApply, when true, blocks until checker reports the applied object
+	// Healthy or Degraded, instead of returning as soon as the apiserver accepts it.
+	WaitAfterApply bool
+	// WaitTimeout bounds how long WaitAfterApply blocks. Defaults to 5 minutes when zero.
+	WaitTimeout time.Duration
+}
+
+const defaultWaitTimeout = 5 * time.Minute
+
+// ApplyAndWait applies obj through handler and, when opts.WaitAfterApply is set, blocks
+// on checker reporting the resulting object ready. This is the entry point the API layer
+// should use when it wants to surface rollout completion (or failure) to the caller
+// instead of returning as soon as the apiserver has accepted the write.
+func ApplyAndWait(ctx context.Context, handler ResourceHandler, checker *HealthChecker, kind, namespace, name string, obj *runtime.Unknown, opts ApplyOptions) (*runtime.Unknown, HealthStatus, string, error) {
+	result, err := handler.Apply(kind, namespace, name, obj, opts.FieldManager, opts.Force)
+	if err != nil {
+		return nil, HealthStatusDegraded, "", err
+	}
+
+	if !opts.WaitAfterApply {
+		return result, "", "", nil
+	}
+
+	timeout := opts.WaitTimeout
+	if timeout == 0 {
+		timeout = defaultWaitTimeout
+	}
+
+	status, message, err := checker.WaitReady(ctx, kind, namespace, name, timeout)
+	return result, status, message, err
+}
@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// installOrder lists kinds in the order a Batch apply installs them in, so dependencies
+// (a Namespace, a ConfigMap a Deployment mounts, a CRD a custom resource needs) exist
+// before the resources that need them are posted. Kinds not listed here are applied last,
+// in the order they were given.
+var installOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"StatefulSet",
+	"Deployment",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+var installOrderIndex = func() map[string]int {
+	index := make(map[string]int, len(installOrder))
+	for i, kind := range installOrder {
+		index[kind] = i
+	}
+	return index
+}()
+
+// manifest pairs a decoded object with the raw bytes Batch was given for it, so ordering
+// and apply can both work off the same parsed metadata.
+type manifest struct {
+	kind      string
+	namespace string
+	name      string
+	raw       *runtime.Unknown
+}
+
+// appliedObject is the pre-image/post-image record Batch keeps for each manifest it
+// successfully applies, so Rollback knows whether to delete or restore it.
+type appliedObject struct {
+	manifest manifest
+	// existedBefore is true when the object already existed prior to this Batch, in which
+	// case Rollback restores preImage instead of deleting the object outright.
+	existedBefore bool
+	preImage      *runtime.Unknown
+}
+
+// BatchResult records what a Batch apply did, in application order, so a caller that
+// wants to roll back a partially-failed batch knows exactly what to undo.
+type BatchResult struct {
+	Applied []appliedObject
+	// Err is the error that stopped the batch, or nil if every manifest applied cleanly.
+	Err error
+	// FailedManifest is the manifest being applied when Err occurred, if any.
+	FailedManifest *manifest
+	// FieldManager is the field manager Batch applied every manifest as. Rollback restores
+	// pre-images through the same Apply path rather than a raw Update, so it needs this to
+	// restore as the same manager.
+	FieldManager string
+}
+
+// Batch applies manifests in dependency order through handler, waiting for any
+// CustomResourceDefinition to reach Established=True before the custom resources that
+// depend on it are applied. It stops at the first failure and returns a BatchResult
+// describing everything applied so far, so the caller can choose to Rollback.
+func Batch(handler ResourceHandler, checker *HealthChecker, manifests []*runtime.Unknown, fieldManager string) *BatchResult {
+	parsed := make([]manifest, 0, len(manifests))
+	for _, raw := range manifests {
+		m, err := parseManifest(raw)
+		if err != nil {
+			return &BatchResult{Err: fmt.Errorf("parse manifest: %v", err)}
+		}
+		parsed = append(parsed, m)
+	}
+
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return orderIndex(parsed[i].kind) < orderIndex(parsed[j].kind)
+	})
+
+	result := &BatchResult{FieldManager: fieldManager}
+	for i := range parsed {
+		m := parsed[i]
+
+		preImage, existedBefore, err := capturePreImage(handler, m)
+		if err != nil {
+			result.Err = err
+			result.FailedManifest = &m
+			return result
+		}
+
+		if _, err := handler.Apply(m.kind, m.namespace, m.name, m.raw, fieldManager, true); err != nil {
+			result.Err = err
+			result.FailedManifest = &m
+			return result
+		}
+
+		result.Applied = append(result.Applied, appliedObject{manifest: m, existedBefore: existedBefore, preImage: preImage})
+
+		if m.kind == "CustomResourceDefinition" && checker != nil {
+			if _, _, err := checker.WaitReady(context.Background(), m.kind, m.namespace, m.name, 2*time.Minute); err != nil {
+				result.Err = fmt.Errorf("wait for CRD %s to become established: %v", m.name, err)
+				result.FailedManifest = &m
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+// Rollback undoes a partially-applied BatchResult: objects that didn't exist before the
+// batch are deleted, and objects that were updated are restored from their captured
+// pre-image. Applied objects are undone in reverse application order, so dependents are
+// removed before what they depend on.
+//
+// Restoring a pre-image goes through Apply rather than Update: the pre-image was captured
+// before Batch's own Apply ran, so its resourceVersion is already stale by the time
+// Rollback runs, and Update's PUT would be rejected with a 409 Conflict on essentially
+// every restore. Apply, as a patch, carries no such resourceVersion precondition.
+func Rollback(handler ResourceHandler, result *BatchResult) error {
+	var firstErr error
+	for i := len(result.Applied) - 1; i >= 0; i-- {
+		applied := result.Applied[i]
+		m := applied.manifest
+
+		var err error
+		if applied.existedBefore {
+			_, err = handler.Apply(m.kind, m.namespace, m.name, applied.preImage, result.FieldManager, true)
+		} else {
+			err = handler.Delete(m.kind, m.namespace, m.name, &meta_v1.DeleteOptions{})
+		}
+
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("rollback %s %s/%s: %v", m.kind, m.namespace, m.name, err)
+		}
+	}
+
+	return firstErr
+}
+
+func orderIndex(kind string) int {
+	if i, ok := installOrderIndex[kind]; ok {
+		return i
+	}
+	return len(installOrder)
+}
+
+func parseManifest(raw *runtime.Unknown) (manifest, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(raw.Raw); err != nil {
+		return manifest{}, err
+	}
+
+	return manifest{
+		kind:      obj.GetKind(),
+		namespace: obj.GetNamespace(),
+		name:      obj.GetName(),
+		raw:       raw,
+	}, nil
+}
+
+// liveReader is implemented by ResourceHandlers that can read straight from the
+// apiserver instead of the (possibly stale) informer cache Get uses.
+type liveReader interface {
+	GetLive(kind string, namespace string, name string) (runtime.Object, error)
+}
+
+// capturePreImage fetches the current state of the object a manifest targets, if it
+// exists, so Rollback can restore it. It always reads live rather than through handler.Get,
+// since a cache that hasn't yet synced an object that does exist on the server would
+// otherwise make Rollback delete something it should have restored instead. A not-found
+// read means the object is new to this batch, which Rollback needs to know to delete
+// rather than restore it.
+func capturePreImage(handler ResourceHandler, m manifest) (*runtime.Unknown, bool, error) {
+	lr, ok := handler.(liveReader)
+	if !ok {
+		return nil, false, fmt.Errorf("ResourceHandler %T does not support live reads required for Batch pre-image capture", handler)
+	}
+
+	obj, err := lr.GetLive(m.kind, m.namespace, m.name)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	if unknown, ok := obj.(*runtime.Unknown); ok {
+		return unknown, true, nil
+	}
+
+	unstructuredObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	u := &unstructured.Unstructured{Object: unstructuredObj}
+	raw, err := u.MarshalJSON()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &runtime.Unknown{Raw: raw}, true, nil
+}
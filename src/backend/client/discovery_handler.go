@@ -0,0 +1,363 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	strategicpatch "k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/Qihoo360/wayne/src/backend/client/api"
+	"github.com/Qihoo360/wayne/src/backend/util/logs"
+)
+
+// DiscoveryResourceHandler is a ResourceHandler that resolves the GroupVersionResource
+// for a kind at request time via the cluster's discovery API, instead of relying solely
+// on the static api.KindToResourceMap. This makes it possible to manage CRDs and any
+// built-in kind the static map hasn't caught up with yet, without recompiling wayne.
+type DiscoveryResourceHandler struct {
+	client        *kubernetes.Clientset
+	dynamicClient dynamic.Interface
+	cacheFactory  *CacheFactory
+
+	mapperLock sync.RWMutex
+	mapper     *restmapper.DeferredDiscoveryRESTMapper
+}
+
+// NewDiscoveryResourceHandler builds a DiscoveryResourceHandler backed by the given
+// clientset's discovery client. The REST mapper, used for kinds in api.KindToResourceMap,
+// is cached and only rebuilt when a lookup reports meta.IsNoMatchError. Kinds missing from
+// that map go through discoverByKind, which queries the discovery API on every request;
+// its results are not cached.
+func NewDiscoveryResourceHandler(kubeClient *kubernetes.Clientset, dynamicClient dynamic.Interface, cacheFactory *CacheFactory) *DiscoveryResourceHandler {
+	discoveryClient := discovery.DiscoveryClient{RESTClient: kubeClient.Discovery().RESTClient()}
+	return &DiscoveryResourceHandler{
+		client:        kubeClient,
+		dynamicClient: dynamicClient,
+		cacheFactory:  cacheFactory,
+		mapper:        restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(&discoveryClient)),
+	}
+}
+
+// resourceFor resolves kind to a GroupVersionResource. Kinds with a static hint in
+// api.KindToResourceMap already know their Group/Version, so those go straight through
+// the REST mapper (falling back to the hint itself if discovery disagrees). Kinds with
+// no hint — CRDs, or any built-in kind the static map hasn't caught up with yet — have
+// no Group to hand the mapper, so their Group/Version/Resource is discovered by scanning
+// the cluster's discovery document for a matching Kind.
+func (h *DiscoveryResourceHandler) resourceFor(kind string) (resource schema.GroupVersionResource, namespaced bool, err error) {
+	hint, hasHint := api.KindToResourceMap[kind]
+	if !hasHint {
+		return h.discoverByKind(kind)
+	}
+
+	gvk := hint.GroupVersionResource.GroupVersion().WithKind(kind)
+	mapping, err := h.restMappingFor(gvk)
+	if err != nil {
+		logs.Warn("Discovery lookup for kind (%s) failed, falling back to static resource map: %v", kind, err)
+		return hint.GroupVersionResource, hint.Namespaced, nil
+	}
+
+	return mapping.Resource, mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// discoverByKind resolves kind to a GroupVersionResource by scanning the cluster's
+// discovery document (ServerGroupsAndResources) for an APIResource whose Kind matches.
+// RESTMapping can't be used here: it maps a GroupKind it's already given to a Resource,
+// but an unhinted kind is exactly a bare Kind string with no Group to look it up by.
+func (h *DiscoveryResourceHandler) discoverByKind(kind string) (schema.GroupVersionResource, bool, error) {
+	_, apiLists, err := h.client.Discovery().ServerGroupsAndResources()
+	if err != nil && len(apiLists) == 0 {
+		return schema.GroupVersionResource{}, false, fmt.Errorf("discover resource for kind (%s): %v", kind, err)
+	}
+
+	for _, list := range apiLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, apiResource := range list.APIResources {
+			// Skip subresources (e.g. "deployments/status") — they share the parent's Kind
+			// but aren't a resource PatchFromDiff/Create/etc. should ever target directly.
+			if apiResource.Kind != kind || strings.Contains(apiResource.Name, "/") {
+				continue
+			}
+			return gv.WithResource(apiResource.Name), apiResource.Namespaced, nil
+		}
+	}
+
+	return schema.GroupVersionResource{}, false, &meta.NoResourceMatchError{PartialResource: schema.GroupVersionResource{Resource: kind}}
+}
+
+func (h *DiscoveryResourceHandler) restMappingFor(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := h.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err == nil {
+		return mapping, nil
+	}
+	if !meta.IsNoMatchError(err) {
+		return nil, err
+	}
+
+	// The mapper's cache is stale (e.g. a CRD was just installed) — rebuild it once and retry.
+	h.mapperLock.Lock()
+	h.mapper.Reset()
+	h.mapperLock.Unlock()
+
+	return h.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+func (h *DiscoveryResourceHandler) Create(kind string, namespace string, object *runtime.Unknown) (*runtime.Unknown, error) {
+	resource, namespaced, err := h.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := unstructuredFromUnknown(object)
+	if err != nil {
+		return nil, err
+	}
+
+	ri := h.dynamicClient.Resource(resource)
+	var result *unstructured.Unstructured
+	if namespaced {
+		result, err = ri.Namespace(namespace).Create(obj, meta_v1.CreateOptions{})
+	} else {
+		result, err = ri.Create(obj, meta_v1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unknownFromUnstructured(result)
+}
+
+func (h *DiscoveryResourceHandler) Update(kind string, namespace string, name string, object *runtime.Unknown) (*runtime.Unknown, error) {
+	resource, namespaced, err := h.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := unstructuredFromUnknown(object)
+	if err != nil {
+		return nil, err
+	}
+	obj.SetName(name)
+
+	ri := h.dynamicClient.Resource(resource)
+	var result *unstructured.Unstructured
+	if namespaced {
+		result, err = ri.Namespace(namespace).Update(obj, meta_v1.UpdateOptions{})
+	} else {
+		result, err = ri.Update(obj, meta_v1.UpdateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unknownFromUnstructured(result)
+}
+
+func (h *DiscoveryResourceHandler) Delete(kind string, namespace string, name string, options *meta_v1.DeleteOptions) error {
+	resource, namespaced, err := h.resourceFor(kind)
+	if err != nil {
+		return err
+	}
+
+	ri := h.dynamicClient.Resource(resource)
+	if namespaced {
+		return ri.Namespace(namespace).Delete(name, options)
+	}
+	return ri.Delete(name, options)
+}
+
+func (h *DiscoveryResourceHandler) Patch(kind string, namespace string, name string, pt types.PatchType, data []byte, subresources ...string) (*runtime.Unknown, error) {
+	resource, namespaced, err := h.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	ri := h.dynamicClient.Resource(resource)
+	var result *unstructured.Unstructured
+	if namespaced {
+		result, err = ri.Namespace(namespace).Patch(name, pt, data, meta_v1.PatchOptions{}, subresources...)
+	} else {
+		result, err = ri.Patch(name, pt, data, meta_v1.PatchOptions{}, subresources...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unknownFromUnstructured(result)
+}
+
+// Apply performs a server-side apply against the dynamic client. As with resourceHandler,
+// every kind is sent as applyPatchType, which needs no registered Go type and so works for
+// arbitrary GVKs.
+func (h *DiscoveryResourceHandler) Apply(kind string, namespace string, name string, obj *runtime.Unknown, fieldManager string, force bool) (*runtime.Unknown, error) {
+	resource, namespaced, err := h.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredObj, err := unstructuredFromUnknown(obj)
+	if err != nil {
+		return nil, err
+	}
+	data, err := unstructuredObj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	ri := h.dynamicClient.Resource(resource)
+	opts := meta_v1.PatchOptions{Force: &force, FieldManager: fieldManager}
+	var result *unstructured.Unstructured
+	if namespaced {
+		result, err = ri.Namespace(namespace).Patch(name, applyPatchType, data, opts)
+	} else {
+		result, err = ri.Patch(name, applyPatchType, data, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unknownFromUnstructured(result)
+}
+
+// GetLive performs a live read straight from the apiserver via the dynamic client,
+// bypassing the informer cache Get uses. Callers that need a guaranteed-fresh read — e.g.
+// Batch capturing a pre-image before an apply it might have to roll back — should use
+// this instead of Get.
+func (h *DiscoveryResourceHandler) GetLive(kind string, namespace string, name string) (runtime.Object, error) {
+	resource, namespaced, err := h.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	ri := h.dynamicClient.Resource(resource)
+	var result *unstructured.Unstructured
+	if namespaced {
+		result, err = ri.Namespace(namespace).Get(name, meta_v1.GetOptions{})
+	} else {
+		result, err = ri.Get(name, meta_v1.GetOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unknownFromUnstructured(result)
+}
+
+// PatchFromDiff computes a patch for kind by diffing current against modified and sends
+// it through Patch. A kind whose resolved GroupVersion has a Go type registered in the
+// scheme gets a strategic-merge patch, as with resourceHandler; CRDs and other
+// unregistered kinds get a JSON merge patch.
+func (h *DiscoveryResourceHandler) PatchFromDiff(kind string, namespace string, name string, current, modified []byte, subresources ...string) (*runtime.Unknown, error) {
+	resource, _, err := h.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	pt := types.MergePatchType
+	if versionedObj, schemeErr := scheme.Scheme.New(resource.GroupVersion().WithKind(kind)); schemeErr == nil {
+		pt = types.StrategicMergePatchType
+		data, err = strategicpatch.CreateTwoWayMergePatch(current, modified, versionedObj)
+	} else {
+		data, err = jsonMergePatch(current, modified)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.Patch(kind, namespace, name, pt, data, subresources...)
+}
+
+// Get object from cache, registering an informer for the resource on demand if one
+// isn't already running.
+func (h *DiscoveryResourceHandler) Get(kind string, namespace string, name string) (runtime.Object, error) {
+	resource, namespaced, err := h.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	genericInformer, err := h.informerFor(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	lister := genericInformer.Lister()
+	if namespaced {
+		return lister.ByNamespace(namespace).Get(name)
+	}
+
+	return lister.Get(name)
+}
+
+// List object from cache, registering an informer for the resource on demand if one
+// isn't already running.
+func (h *DiscoveryResourceHandler) List(kind string, namespace string, labelSelector string) ([]runtime.Object, error) {
+	resource, namespaced, err := h.resourceFor(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	genericInformer, err := h.informerFor(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	selectors, err := labels.Parse(labelSelector)
+	if err != nil {
+		logs.Error("Build label selector error.", err)
+		return nil, err
+	}
+
+	lister := genericInformer.Lister()
+	if namespaced {
+		return lister.ByNamespace(namespace).List(selectors)
+	}
+
+	return lister.List(selectors)
+}
+
+// informerFor registers an informer for resource against the shared CacheFactory the
+// first time it's requested.
+func (h *DiscoveryResourceHandler) informerFor(resource schema.GroupVersionResource) (informers.GenericInformer, error) {
+	return h.cacheFactory.sharedInformerFactory.ForResource(resource)
+}
+
+// EvictResource stops and forgets the informer registered for resource, if any. It
+// should be invoked when a CRD backing that resource has been deleted, so wayne doesn't
+// keep watching an API that the apiserver has stopped serving.
+func (h *DiscoveryResourceHandler) EvictResource(resource schema.GroupVersionResource) {
+	h.cacheFactory.evictResource(resource)
+}
+
+func unstructuredFromUnknown(object *runtime.Unknown) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(object.Raw); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func unknownFromUnstructured(obj *unstructured.Unstructured) (*runtime.Unknown, error) {
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.Unknown{Raw: raw}, nil
+}
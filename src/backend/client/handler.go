@@ -8,6 +8,7 @@ import (
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/Qihoo360/wayne/src/backend/client/api"
@@ -17,6 +18,19 @@ import (
 type ResourceHandler interface {
 	Create(kind string, namespace string, object *runtime.Unknown) (*runtime.Unknown, error)
 	Update(kind string, namespace string, name string, object *runtime.Unknown) (*runtime.Unknown, error)
+	// Patch partially updates name with data, using pt to decide how data is merged into the
+	// existing object. subresources targets a subresource (e.g. "status", "scale") instead of
+	// the main object when given.
+	Patch(kind string, namespace string, name string, pt types.PatchType, data []byte, subresources ...string) (*runtime.Unknown, error)
+	// Apply performs a Kubernetes server-side apply of obj, owned by fieldManager. force
+	// resolves conflicts with other field managers in favor of this apply when true, matching
+	// how kubectl apply --force-conflicts behaves.
+	Apply(kind string, namespace string, name string, obj *runtime.Unknown, fieldManager string, force bool) (*runtime.Unknown, error)
+	// PatchFromDiff computes a patch for kind by diffing current against modified and sends
+	// it through Patch, picking the same patch type kubectl/helm would for a three-way merge:
+	// a strategic-merge patch when the scheme has a registered Go type for kind, falling back
+	// to a JSON merge patch for CRDs and other unregistered kinds.
+	PatchFromDiff(kind string, namespace string, name string, current, modified []byte, subresources ...string) (*runtime.Unknown, error)
 	Get(kind string, namespace string, name string) (runtime.Object, error)
 	List(kind string, namespace string, labelSelector string) ([]runtime.Object, error)
 	Delete(kind string, namespace string, name string, options *meta_v1.DeleteOptions) error
@@ -92,6 +106,29 @@ func (h *resourceHandler) Delete(kind string, namespace string, name string, opt
 	return req.Do().Error()
 }
 
+// GetLive performs a live read straight from the apiserver, bypassing the informer cache
+// Get uses. Callers that need a guaranteed-fresh read — e.g. Batch capturing a pre-image
+// before an apply it might have to roll back — should use this instead of Get, since the
+// cache can lag the server by as much as a resync period.
+func (h *resourceHandler) GetLive(kind string, namespace string, name string) (runtime.Object, error) {
+	resource, ok := api.KindToResourceMap[kind]
+	if !ok {
+		return nil, fmt.Errorf("Resource kind (%s) not support yet . ", kind)
+	}
+	kubeClient := h.getClientByGroupVersion(resource.GroupVersionResource)
+	req := kubeClient.Get().
+		Resource(kind).
+		Name(name)
+	if resource.Namespaced {
+		req.Namespace(namespace)
+	}
+
+	var result runtime.Unknown
+	err := req.Do().Into(&result)
+
+	return &result, err
+}
+
 // Get object from cache
 func (h *resourceHandler) Get(kind string, namespace string, name string) (runtime.Object, error) {
 	resource, ok := api.KindToResourceMap[kind]
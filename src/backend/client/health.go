@@ -0,0 +1,372 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+	apiext_v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// HealthStatus summarizes the readiness of a resource, mirroring the handful of states
+// most Kubernetes controllers converge to. It's intentionally coarse — callers that need
+// the raw object should go through ResourceHandler.Get instead.
+type HealthStatus string
+
+const (
+	HealthStatusHealthy     HealthStatus = "Healthy"
+	HealthStatusProgressing HealthStatus = "Progressing"
+	HealthStatusDegraded    HealthStatus = "Degraded"
+	HealthStatusSuspended   HealthStatus = "Suspended"
+	HealthStatusMissing     HealthStatus = "Missing"
+)
+
+// resourceChecker inspects a single object and reports its health. Built-in kinds are
+// backed by a typeChecker; CRDs are backed by a luaChecker evaluating a user-supplied
+// script. ctx bounds the check itself — built-in checkers ignore it since they only ever
+// inspect an in-memory object, but luaChecker uses it to cut off a runaway script.
+type resourceChecker interface {
+	Check(ctx context.Context, obj runtime.Object) (HealthStatus, string)
+}
+
+// HealthChecker answers "is this resource actually ready yet" on top of a ResourceHandler,
+// for kinds whose readiness can't be inferred from Create/Update succeeding alone (rollouts,
+// Job completion, PVC binding, ...). CRD authors can register a Lua health check per GVK
+// instead of wayne shipping a Go checker for every possible CRD.
+type HealthChecker struct {
+	handler ResourceHandler
+
+	checkersLock sync.RWMutex
+	checkers     map[schema.GroupVersionKind]resourceChecker
+}
+
+// NewHealthChecker wires a HealthChecker on top of handler, pre-registering the built-in
+// checkers for the workload kinds wayne manages out of the box.
+func NewHealthChecker(handler ResourceHandler) *HealthChecker {
+	h := &HealthChecker{
+		handler:  handler,
+		checkers: make(map[schema.GroupVersionKind]resourceChecker),
+	}
+
+	h.checkers[schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}] = deploymentChecker{}
+	h.checkers[schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}] = statefulSetChecker{}
+	h.checkers[schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}] = daemonSetChecker{}
+	h.checkers[schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}] = jobChecker{}
+	h.checkers[schema.GroupVersionKind{Version: "v1", Kind: "Pod"}] = podChecker{}
+	h.checkers[schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"}] = pvcChecker{}
+	h.checkers[schema.GroupVersionKind{Version: "v1", Kind: "Service"}] = serviceChecker{}
+	h.checkers[schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}] = crdChecker{}
+
+	return h
+}
+
+// RegisterLuaCheck installs a Lua health check for gvk, overriding any built-in checker
+// already registered for it. The script receives the object as a Lua table named `obj`
+// and must return a table shaped `{status=..., message=...}`; status must be one of the
+// HealthStatus values above.
+func (h *HealthChecker) RegisterLuaCheck(gvk schema.GroupVersionKind, script string) error {
+	checker, err := newLuaChecker(gvk, script)
+	if err != nil {
+		return err
+	}
+
+	h.checkersLock.Lock()
+	h.checkers[gvk] = checker
+	h.checkersLock.Unlock()
+
+	return nil
+}
+
+// Status reports the current health of the named resource without an overall deadline
+// of its own — any Lua check it runs still carries defaultLuaCheckTimeout so a runaway
+// script can't hang this call forever.
+func (h *HealthChecker) Status(kind string, namespace string, name string) (HealthStatus, string) {
+	return h.statusWithContext(context.Background(), kind, namespace, name)
+}
+
+// statusWithContext is Status with ctx threaded all the way down to the Get and the
+// checker, so WaitReady's timeout actually bounds every step of a single poll instead of
+// just the sleep between polls.
+func (h *HealthChecker) statusWithContext(ctx context.Context, kind string, namespace string, name string) (HealthStatus, string) {
+	type getResult struct {
+		obj runtime.Object
+		err error
+	}
+	resultCh := make(chan getResult, 1)
+	go func() {
+		obj, err := h.handler.Get(kind, namespace, name)
+		resultCh <- getResult{obj, err}
+	}()
+
+	var obj runtime.Object
+	var err error
+	select {
+	case <-ctx.Done():
+		return HealthStatusProgressing, fmt.Sprintf("health check for %s %s/%s timed out", kind, namespace, name)
+	case r := <-resultCh:
+		obj, err = r.obj, r.err
+	}
+
+	if err != nil {
+		if isNotFound(err) {
+			return HealthStatusMissing, fmt.Sprintf("%s %s/%s not found", kind, namespace, name)
+		}
+		return HealthStatusDegraded, err.Error()
+	}
+
+	checker := h.checkerFor(obj.GetObjectKind().GroupVersionKind(), kind)
+	if checker == nil {
+		// No built-in or registered Lua checker for this kind — the caller asked us to wait
+		// on something we have no way to judge, so treat "exists" as "healthy".
+		return HealthStatusHealthy, fmt.Sprintf("%s %s/%s exists, no health check registered", kind, namespace, name)
+	}
+
+	return checker.Check(ctx, obj)
+}
+
+func (h *HealthChecker) checkerFor(gvk schema.GroupVersionKind, kind string) resourceChecker {
+	h.checkersLock.RLock()
+	defer h.checkersLock.RUnlock()
+
+	if checker, ok := h.checkers[gvk]; ok {
+		return checker
+	}
+	// The object's own GVK is sometimes blank coming out of an informer cache; fall back to
+	// matching on Kind alone against whatever's registered.
+	for registered, checker := range h.checkers {
+		if registered.Kind == kind {
+			return checker
+		}
+	}
+	return nil
+}
+
+// WaitReady polls Status until it reports Healthy, Degraded or ctx is done, whichever
+// comes first. Progressing and Suspended keep the wait going.
+func (h *HealthChecker) WaitReady(ctx context.Context, kind string, namespace string, name string, timeout time.Duration) (HealthStatus, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		status, message := h.statusWithContext(ctx, kind, namespace, name)
+		switch status {
+		case HealthStatusHealthy, HealthStatusDegraded:
+			return status, message, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, message, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type deploymentChecker struct{}
+
+func (deploymentChecker) Check(_ context.Context, obj runtime.Object) (HealthStatus, string) {
+	d, ok := obj.(*apps_v1.Deployment)
+	if !ok {
+		return HealthStatusDegraded, "object is not a Deployment"
+	}
+
+	if d.Spec.Paused {
+		return HealthStatusSuspended, "deployment is paused"
+	}
+	if d.Status.ObservedGeneration < d.Generation {
+		return HealthStatusProgressing, "waiting for deployment spec update to be observed"
+	}
+	for _, cond := range d.Status.Conditions {
+		if cond.Type == apps_v1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return HealthStatusDegraded, cond.Message
+		}
+	}
+	if d.Status.UpdatedReplicas < desiredReplicas(d.Spec.Replicas) {
+		return HealthStatusProgressing, fmt.Sprintf("%d of %d replicas updated", d.Status.UpdatedReplicas, desiredReplicas(d.Spec.Replicas))
+	}
+	if d.Status.AvailableReplicas < desiredReplicas(d.Spec.Replicas) {
+		return HealthStatusProgressing, fmt.Sprintf("%d of %d replicas available", d.Status.AvailableReplicas, desiredReplicas(d.Spec.Replicas))
+	}
+
+	return HealthStatusHealthy, "deployment rollout complete"
+}
+
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+type statefulSetChecker struct{}
+
+func (statefulSetChecker) Check(_ context.Context, obj runtime.Object) (HealthStatus, string) {
+	s, ok := obj.(*apps_v1.StatefulSet)
+	if !ok {
+		return HealthStatusDegraded, "object is not a StatefulSet"
+	}
+
+	if s.Status.ObservedGeneration < s.Generation {
+		return HealthStatusProgressing, "waiting for statefulset spec update to be observed"
+	}
+	if s.Status.UpdatedReplicas < desiredReplicas(s.Spec.Replicas) || s.Status.ReadyReplicas < desiredReplicas(s.Spec.Replicas) {
+		return HealthStatusProgressing, fmt.Sprintf("%d of %d replicas ready", s.Status.ReadyReplicas, desiredReplicas(s.Spec.Replicas))
+	}
+
+	return HealthStatusHealthy, "statefulset rollout complete"
+}
+
+type daemonSetChecker struct{}
+
+func (daemonSetChecker) Check(_ context.Context, obj runtime.Object) (HealthStatus, string) {
+	d, ok := obj.(*apps_v1.DaemonSet)
+	if !ok {
+		return HealthStatusDegraded, "object is not a DaemonSet"
+	}
+
+	if d.Status.ObservedGeneration < d.Generation {
+		return HealthStatusProgressing, "waiting for daemonset spec update to be observed"
+	}
+	if d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled || d.Status.NumberAvailable < d.Status.DesiredNumberScheduled {
+		return HealthStatusProgressing, fmt.Sprintf("%d of %d scheduled", d.Status.UpdatedNumberScheduled, d.Status.DesiredNumberScheduled)
+	}
+
+	return HealthStatusHealthy, "daemonset rollout complete"
+}
+
+type jobChecker struct{}
+
+func (jobChecker) Check(_ context.Context, obj runtime.Object) (HealthStatus, string) {
+	j, ok := obj.(*batch_v1.Job)
+	if !ok {
+		return HealthStatusDegraded, "object is not a Job"
+	}
+
+	for _, cond := range j.Status.Conditions {
+		if cond.Status != core_v1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batch_v1.JobComplete:
+			return HealthStatusHealthy, "job completed"
+		case batch_v1.JobFailed:
+			return HealthStatusDegraded, cond.Message
+		}
+	}
+
+	return HealthStatusProgressing, fmt.Sprintf("%d active, %d succeeded", j.Status.Active, j.Status.Succeeded)
+}
+
+type podChecker struct{}
+
+func (podChecker) Check(_ context.Context, obj runtime.Object) (HealthStatus, string) {
+	p, ok := obj.(*core_v1.Pod)
+	if !ok {
+		return HealthStatusDegraded, "object is not a Pod"
+	}
+
+	switch p.Status.Phase {
+	case core_v1.PodRunning, core_v1.PodSucceeded:
+		for _, cond := range p.Status.Conditions {
+			if cond.Type == core_v1.PodReady && cond.Status != core_v1.ConditionTrue {
+				return HealthStatusProgressing, cond.Message
+			}
+		}
+		return HealthStatusHealthy, string(p.Status.Phase)
+	case core_v1.PodFailed:
+		return HealthStatusDegraded, p.Status.Message
+	default:
+		return HealthStatusProgressing, string(p.Status.Phase)
+	}
+}
+
+type pvcChecker struct{}
+
+func (pvcChecker) Check(_ context.Context, obj runtime.Object) (HealthStatus, string) {
+	p, ok := obj.(*core_v1.PersistentVolumeClaim)
+	if !ok {
+		return HealthStatusDegraded, "object is not a PersistentVolumeClaim"
+	}
+
+	if p.Status.Phase == core_v1.ClaimBound {
+		return HealthStatusHealthy, "pvc bound"
+	}
+	if p.Status.Phase == core_v1.ClaimLost {
+		return HealthStatusDegraded, "pvc lost"
+	}
+	return HealthStatusProgressing, string(p.Status.Phase)
+}
+
+type serviceChecker struct{}
+
+func (serviceChecker) Check(_ context.Context, obj runtime.Object) (HealthStatus, string) {
+	s, ok := obj.(*core_v1.Service)
+	if !ok {
+		return HealthStatusDegraded, "object is not a Service"
+	}
+
+	if s.Spec.Type != core_v1.ServiceTypeLoadBalancer {
+		return HealthStatusHealthy, "service has no load balancer to wait on"
+	}
+	if len(s.Status.LoadBalancer.Ingress) > 0 {
+		return HealthStatusHealthy, "load balancer ingress assigned"
+	}
+	return HealthStatusProgressing, "waiting for load balancer ingress"
+}
+
+func isNotFound(err error) bool {
+	return apierrors.IsNotFound(err)
+}
+
+type crdChecker struct{}
+
+// Check reports a CustomResourceDefinition healthy only once its Established condition is
+// True, matching what Batch needs before applying custom resources that depend on it.
+// The informer backing Get may hand back either the typed apiextensions object or an
+// unstructured.Unstructured (when sourced from a dynamic informer), so both are handled.
+func (crdChecker) Check(_ context.Context, obj runtime.Object) (HealthStatus, string) {
+	if crd, ok := obj.(*apiext_v1.CustomResourceDefinition); ok {
+		for _, cond := range crd.Status.Conditions {
+			if cond.Type != apiext_v1.Established {
+				continue
+			}
+			if cond.Status == apiext_v1.ConditionTrue {
+				return HealthStatusHealthy, "CRD established"
+			}
+			return HealthStatusProgressing, cond.Message
+		}
+		return HealthStatusProgressing, "waiting for CRD to become established"
+	}
+
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return HealthStatusDegraded, fmt.Sprintf("inspect CRD status: %v", err)
+	}
+	conditions, found, err := unstructured.NestedSlice(u, "status", "conditions")
+	if err != nil || !found {
+		return HealthStatusProgressing, "waiting for CRD status to be populated"
+	}
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != "Established" {
+			continue
+		}
+		if cond["status"] == "True" {
+			return HealthStatusHealthy, "CRD established"
+		}
+		message, _ := cond["message"].(string)
+		return HealthStatusProgressing, message
+	}
+	return HealthStatusProgressing, "waiting for CRD to become established"
+}
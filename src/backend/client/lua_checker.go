@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// defaultLuaCheckTimeout bounds a Lua health check's execution when the caller's ctx has
+// no deadline of its own (e.g. a direct HealthChecker.Status call outside WaitReady), so a
+// CRD script that loops forever can't hang the caller indefinitely.
+const defaultLuaCheckTimeout = 10 * time.Second
+
+// scriptCacheKey identifies a compiled script by both its GVK and its own text, so
+// RegisterLuaCheck replacing a GVK's script with a different one (e.g. fixing a bug in a
+// CRD's health check) compiles and runs the new script instead of silently keeping the
+// stale proto cached under the same GroupKind forever.
+type scriptCacheKey struct {
+	gk     schema.GroupKind
+	script string
+}
+
+// scriptCache holds compiled Lua health-check scripts keyed on scriptCacheKey, so a CRD
+// with many objects and frequent Status() calls doesn't re-parse its script every time.
+var scriptCache = struct {
+	sync.RWMutex
+	protos map[scriptCacheKey]*lua.FunctionProto
+}{protos: make(map[scriptCacheKey]*lua.FunctionProto)}
+
+// luaChecker evaluates a user-supplied Lua script against an object to decide its
+// HealthStatus, for GVKs wayne has no built-in Go checker for (arbitrary CRDs).
+type luaChecker struct {
+	gk     schema.GroupKind
+	script string
+}
+
+func newLuaChecker(gvk schema.GroupVersionKind, script string) (*luaChecker, error) {
+	gk := gvk.GroupKind()
+	if _, err := compileLuaScript(gk, script); err != nil {
+		return nil, err
+	}
+	return &luaChecker{gk: gk, script: script}, nil
+}
+
+func compileLuaScript(gk schema.GroupKind, script string) (*lua.FunctionProto, error) {
+	key := scriptCacheKey{gk: gk, script: script}
+
+	scriptCache.RLock()
+	proto, ok := scriptCache.protos[key]
+	scriptCache.RUnlock()
+	if ok {
+		return proto, nil
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(script), gk.String())
+	if err != nil {
+		return nil, fmt.Errorf("parse health check script for %s: %v", gk, err)
+	}
+	proto, err = lua.Compile(chunk, gk.String())
+	if err != nil {
+		return nil, fmt.Errorf("compile health check script for %s: %v", gk, err)
+	}
+
+	scriptCache.Lock()
+	scriptCache.protos[key] = proto
+	scriptCache.Unlock()
+
+	return proto, nil
+}
+
+// Check runs the script in a fresh, sandboxed lua.LState — no stdlib beyond
+// base/table/string/math is opened, and the base library's dofile/loadfile are removed, so
+// a misbehaving CRD script can't touch the filesystem or network. The
+// LState's context is set to ctx (falling back to defaultLuaCheckTimeout when ctx has no
+// deadline of its own), so gopher-lua aborts the script once it's exceeded instead of
+// running unbounded.
+func (c *luaChecker) Check(ctx context.Context, obj runtime.Object) (HealthStatus, string) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultLuaCheckTimeout)
+		defer cancel()
+	}
+
+	proto, err := compileLuaScript(c.gk, c.script)
+	if err != nil {
+		return HealthStatusDegraded, err.Error()
+	}
+
+	unstructuredObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return HealthStatusDegraded, fmt.Sprintf("convert object for health check: %v", err)
+	}
+
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+	L.SetContext(ctx)
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		if err := L.PCall(1, 0, nil); err != nil {
+			return HealthStatusDegraded, fmt.Sprintf("init health check sandbox: %v", err)
+		}
+	}
+	L.SetGlobal("dofile", lua.LNil)
+	L.SetGlobal("loadfile", lua.LNil)
+
+	L.SetGlobal("obj", goValueToLua(L, unstructuredObj))
+
+	lfunc := L.NewFunctionFromProto(proto)
+	L.Push(lfunc)
+	if err := L.PCall(0, 1, nil); err != nil {
+		return HealthStatusDegraded, fmt.Sprintf("run health check script: %v", err)
+	}
+
+	ret, ok := L.Get(-1).(*lua.LTable)
+	if !ok {
+		return HealthStatusDegraded, "health check script must return a table {status=..., message=...}"
+	}
+
+	status := HealthStatus(lua.LVAsString(ret.RawGetString("status")))
+	message := lua.LVAsString(ret.RawGetString("message"))
+	switch status {
+	case HealthStatusHealthy, HealthStatusProgressing, HealthStatusDegraded, HealthStatusSuspended, HealthStatusMissing:
+		return status, message
+	default:
+		return HealthStatusDegraded, fmt.Sprintf("health check script returned unknown status %q", status)
+	}
+}
+
+// goValueToLua converts the plain maps/slices/scalars produced by json decoding (and by
+// runtime.DefaultUnstructuredConverter) into the equivalent Lua value, so a CRD object
+// reaches the script as a nested Lua table.
+func goValueToLua(L *lua.LState, v interface{}) lua.LValue {
+	switch val := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(val)
+	case string:
+		return lua.LString(val)
+	case float64:
+		return lua.LNumber(val)
+	case int64:
+		return lua.LNumber(val)
+	case json.Number:
+		f, _ := val.Float64()
+		return lua.LNumber(f)
+	case map[string]interface{}:
+		table := L.NewTable()
+		for key, item := range val {
+			table.RawSetString(key, goValueToLua(L, item))
+		}
+		return table
+	case []interface{}:
+		table := L.NewTable()
+		for i, item := range val {
+			table.RawSetInt(i+1, goValueToLua(L, item))
+		}
+		return table
+	default:
+		return lua.LString(fmt.Sprintf("%v", val))
+	}
+}
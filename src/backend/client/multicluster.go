@@ -0,0 +1,259 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	rest "k8s.io/client-go/rest"
+
+	"github.com/Qihoo360/wayne/src/backend/util/logs"
+)
+
+// clusterEntry is everything MultiClusterResourceHandler needs to drive one cluster:
+// the REST config it was registered with, the ResourceHandler built on top of it, and
+// whether the last health probe saw it reachable, so recordHealth can tell a fresh
+// disconnect from a reconnect and log the reconnect. Informer caches are not resynced on
+// a reconnect.
+type clusterEntry struct {
+	config  *rest.Config
+	handler ResourceHandler
+
+	healthMu sync.Mutex
+	healthy  bool
+}
+
+// MultiResult is the outcome of a fan-out operation across clusters: results are keyed
+// by cluster name, with per-cluster errors kept separate from a successful zero-value
+// result so a caller can tell "cluster unreachable" from "resource doesn't exist there".
+type MultiResult struct {
+	Results map[string]interface{}
+	Errors  map[string]error
+}
+
+// Err returns a combined error describing every cluster that failed, or nil if every
+// cluster in the result succeeded.
+func (r *MultiResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d clusters failed: %v", len(r.Errors), len(r.Errors)+len(r.Results), r.Errors)
+}
+
+// MultiClusterResourceHandler keeps a registry of ResourceHandlers, one per cluster, and
+// lets callers address a single cluster or fan an operation out across several without
+// maintaining their own cluster→handler map.
+type MultiClusterResourceHandler struct {
+	// parallelism bounds how many clusters OnClusters/Broadcast touch concurrently, so a
+	// fan-out across a large fleet doesn't open hundreds of connections at once.
+	parallelism int
+
+	mu       sync.RWMutex
+	clusters map[string]*clusterEntry
+}
+
+// NewMultiClusterResourceHandler builds an empty registry. Clusters are added with
+// AddCluster as wayne discovers/connects to them.
+func NewMultiClusterResourceHandler(parallelism int) *MultiClusterResourceHandler {
+	if parallelism <= 0 {
+		parallelism = 8
+	}
+	return &MultiClusterResourceHandler{
+		parallelism: parallelism,
+		clusters:    make(map[string]*clusterEntry),
+	}
+}
+
+// AddCluster registers name, building a ResourceHandler from kubeClient/cacheFactory.
+// Calling AddCluster again for an existing name replaces its handler and stops the old
+// cacheFactory, e.g. after a reconnect with a refreshed token.
+func (m *MultiClusterResourceHandler) AddCluster(name string, config *rest.Config, kubeClient *kubernetes.Clientset, cacheFactory *CacheFactory) {
+	entry := &clusterEntry{
+		config:  config,
+		handler: NewResourceHandler(kubeClient, cacheFactory),
+		healthy: true,
+	}
+
+	m.mu.Lock()
+	old, existed := m.clusters[name]
+	m.clusters[name] = entry
+	m.mu.Unlock()
+
+	if existed {
+		m.evictCache(old)
+	}
+}
+
+// RemoveCluster evicts name from the registry and stops its informer caches, so a
+// decommissioned cluster doesn't keep consuming watch connections.
+func (m *MultiClusterResourceHandler) RemoveCluster(name string) {
+	m.mu.Lock()
+	entry, ok := m.clusters[name]
+	delete(m.clusters, name)
+	m.mu.Unlock()
+
+	if ok {
+		m.evictCache(entry)
+	}
+}
+
+func (m *MultiClusterResourceHandler) evictCache(entry *clusterEntry) {
+	if rh, ok := entry.handler.(*resourceHandler); ok && rh.cacheFactory != nil {
+		rh.cacheFactory.Stop()
+	}
+}
+
+// ForCluster returns the ResourceHandler registered for name, or nil if no such cluster
+// has been added.
+func (m *MultiClusterResourceHandler) ForCluster(name string) ResourceHandler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.clusters[name]
+	if !ok {
+		return nil
+	}
+	return entry.handler
+}
+
+// Clusters lists the names of every cluster currently registered.
+func (m *MultiClusterResourceHandler) Clusters() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.clusters))
+	for name := range m.clusters {
+		names = append(names, name)
+	}
+	return names
+}
+
+// HealthProbe checks each named cluster's API server by issuing a discovery call,
+// reporting per-cluster reachability. Clusters that aren't registered are reported as
+// errors rather than silently skipped.
+func (m *MultiClusterResourceHandler) HealthProbe(names []string) map[string]error {
+	results := make(map[string]error, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, m.parallelism)
+
+	for _, name := range names {
+		name := name
+		m.mu.RLock()
+		entry, ok := m.clusters[name]
+		m.mu.RUnlock()
+		if !ok {
+			mu.Lock()
+			results[name] = fmt.Errorf("cluster %s is not registered", name)
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			probeClient, err := kubernetes.NewForConfig(entry.config)
+			if err == nil {
+				_, err = probeClient.Discovery().ServerVersion()
+			}
+
+			m.recordHealth(entry, name, err == nil)
+
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// recordHealth updates entry's last-known reachability and logs an unreachable→reachable
+// transition. It does not touch the cluster's informers: their reflectors already
+// re-establish their own watches against the apiserver on a dropped connection, and
+// SharedInformerFactory.Start is a no-op for informers that were already started, so
+// calling it again here wouldn't do anything a reflector hasn't already done itself.
+func (m *MultiClusterResourceHandler) recordHealth(entry *clusterEntry, name string, reachable bool) {
+	entry.healthMu.Lock()
+	wasHealthy := entry.healthy
+	entry.healthy = reachable
+	entry.healthMu.Unlock()
+
+	if reachable && !wasHealthy {
+		logs.Info("Cluster %s is reachable again", name)
+	}
+}
+
+// WatchHealth probes every registered cluster every interval until stopCh is closed, so a
+// cluster becoming reachable again gets logged even when nothing else is actively touching
+// it; a cluster becoming unreachable is recorded but not logged. Callers should run this in its own goroutine for the lifetime of the
+// MultiClusterResourceHandler.
+func (m *MultiClusterResourceHandler) WatchHealth(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			m.HealthProbe(m.Clusters())
+		}
+	}
+}
+
+// OnClusters runs op against each named cluster concurrently, bounded by m.parallelism,
+// and aggregates results and errors per cluster. A cluster that isn't registered is
+// reported as an error rather than silently skipped.
+func (m *MultiClusterResourceHandler) OnClusters(names []string, op func(handler ResourceHandler) (interface{}, error)) *MultiResult {
+	result := &MultiResult{Results: make(map[string]interface{}), Errors: make(map[string]error)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, m.parallelism)
+
+	for _, name := range names {
+		name := name
+		handler := m.ForCluster(name)
+		if handler == nil {
+			mu.Lock()
+			result.Errors[name] = fmt.Errorf("cluster %s is not registered", name)
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			value, err := op(handler)
+			mu.Lock()
+			if err != nil {
+				result.Errors[name] = err
+			} else {
+				result.Results[name] = value
+			}
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	return result
+}
+
+// Broadcast applies the same manifest to kind/namespace/name on every named cluster
+// concurrently, which is what wayne needs to drive a single federated deployment across
+// a fleet without an external orchestrator. Use OnClusters directly for operations other
+// than apply.
+func (m *MultiClusterResourceHandler) Broadcast(names []string, kind string, namespace string, name string, obj *runtime.Unknown, fieldManager string) *MultiResult {
+	return m.OnClusters(names, func(handler ResourceHandler) (interface{}, error) {
+		return handler.Apply(kind, namespace, name, obj, fieldManager, true)
+	})
+}
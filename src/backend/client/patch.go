@@ -0,0 +1,110 @@
+package client
+
+import (
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	strategicpatch "k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/Qihoo360/wayne/src/backend/client/api"
+)
+
+// applyPatchType is the content-type Kubernetes server-side apply expects. It isn't
+// exported by client-go's types package, so wayne defines it alongside the rest of
+// the supported patch types.
+const applyPatchType types.PatchType = "application/apply-patch+yaml"
+
+func (h *resourceHandler) Patch(kind string, namespace string, name string, pt types.PatchType, data []byte, subresources ...string) (*runtime.Unknown, error) {
+	resource, ok := api.KindToResourceMap[kind]
+	if !ok {
+		return nil, fmt.Errorf("Resource kind (%s) not support yet . ", kind)
+	}
+
+	kubeClient := h.getClientByGroupVersion(resource.GroupVersionResource)
+	req := kubeClient.Patch(pt).
+		Resource(kind).
+		Name(name).
+		SubResource(subresources...).
+		Body(data)
+	if resource.Namespaced {
+		req.Namespace(namespace)
+	}
+
+	var result runtime.Unknown
+	err := req.Do().Into(&result)
+
+	return &result, err
+}
+
+// Apply performs a server-side apply of obj as fieldManager, creating the object if it
+// doesn't already exist. Every kind is sent as applyPatchType, so server-side apply's own
+// merge on the apiserver decides how obj is combined with the live object.
+func (h *resourceHandler) Apply(kind string, namespace string, name string, obj *runtime.Unknown, fieldManager string, force bool) (*runtime.Unknown, error) {
+	resource, ok := api.KindToResourceMap[kind]
+	if !ok {
+		return nil, fmt.Errorf("Resource kind (%s) not support yet . ", kind)
+	}
+
+	kubeClient := h.getClientByGroupVersion(resource.GroupVersionResource)
+	req := kubeClient.Patch(applyPatchType).
+		Resource(kind).
+		Name(name).
+		Param("fieldManager", fieldManager).
+		Param("force", fmt.Sprintf("%t", force)).
+		Body([]byte(obj.Raw))
+	if resource.Namespaced {
+		req.Namespace(namespace)
+	}
+
+	var result runtime.Unknown
+	err := req.Do().Into(&result)
+
+	return &result, err
+}
+
+// PatchFromDiff computes a patch for kind by diffing current against modified, then sends
+// it through Patch. This is the entry point for partial updates when a caller has full
+// before/after object bytes instead of a pre-built patch — it picks the same patch type
+// kubectl/helm would for a three-way merge instead of forcing the caller to know it.
+func (h *resourceHandler) PatchFromDiff(kind string, namespace string, name string, current, modified []byte, subresources ...string) (*runtime.Unknown, error) {
+	data, pt, err := strategicMergePatch(kind, current, modified)
+	if err != nil {
+		return nil, err
+	}
+	return h.Patch(kind, namespace, name, pt, data, subresources...)
+}
+
+// strategicMergePatch builds a strategic-merge-style patch for kind by diffing current
+// against modified using the scheme's registered Go type, the patch type kubectl/helm use
+// for such kinds. It is a two-way diff: no last-applied configuration is taken into
+// account. Kinds the scheme doesn't know (CRDs, unregistered types) fall back to a plain
+// JSON merge patch.
+func strategicMergePatch(kind string, current, modified []byte) ([]byte, types.PatchType, error) {
+	resource, ok := api.KindToResourceMap[kind]
+	if !ok {
+		// Not in the static map (e.g. a custom resource) — fall back to a JSON merge patch.
+		patch, err := jsonMergePatch(current, modified)
+		return patch, types.MergePatchType, err
+	}
+
+	versionedObj, err := scheme.Scheme.New(resource.GroupVersionResource.GroupVersion().WithKind(kind))
+	if err != nil {
+		// No Go type registered for this kind (e.g. a CRD) — fall back to a JSON merge patch.
+		patch, err := jsonMergePatch(current, modified)
+		return patch, types.MergePatchType, err
+	}
+
+	patch, err := strategicpatch.CreateTwoWayMergePatch(current, modified, versionedObj)
+	if err != nil {
+		return nil, types.StrategicMergePatchType, err
+	}
+
+	return patch, types.StrategicMergePatchType, nil
+}
+
+func jsonMergePatch(current, modified []byte) ([]byte, error) {
+	return jsonpatch.CreateMergePatch(current, modified)
+}